@@ -0,0 +1,227 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/gomemcached"
+)
+
+// An ASCIIClient talks the legacy text memcached protocol, but exposes
+// the same Get/Set/Add/Del/Stats/StatsMap methods as Client so callers
+// of those don't need to care which wire format they're speaking. It has
+// no Send, Transmit/Receive, GetMulti, Bulk* or Auth methods.
+type ASCIIClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// NewASCIIClient connects to a server that only speaks the ASCII protocol.
+func NewASCIIClient(prot, dest string) (rv *ASCIIClient, err error) {
+	conn, err := net.Dial(prot, dest)
+	if err != nil {
+		return nil, err
+	}
+	return &ASCIIClient{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriterSize(conn, bufsize),
+	}, nil
+}
+
+// Close the connection when you're done.
+func (c *ASCIIClient) Close() {
+	c.conn.Close()
+}
+
+func (c *ASCIIClient) sendLine(line string) error {
+	_, err := c.writer.WriteString(line + "\r\n")
+	if err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
+
+func (c *ASCIIClient) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Get the value for a key. The vbucket id is accepted for interface
+// compatibility with Client, but the ASCII protocol has no notion of one.
+func (c *ASCIIClient) Get(vb uint16, key string) (rv gomemcached.MCResponse, err error) {
+	if err = c.sendLine("get " + key); err != nil {
+		return rv, err
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return rv, err
+	}
+	if line == "END" {
+		rv.Status = gomemcached.KEY_ENOENT
+		return rv, nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) != 4 || fields[0] != "VALUE" {
+		return rv, fmt.Errorf("ascii: unexpected get response: %q", line)
+	}
+	flags, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return rv, err
+	}
+	n, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return rv, err
+	}
+
+	body := make([]byte, n)
+	if _, err = readAll(c.reader, body); err != nil {
+		return rv, err
+	}
+	if _, err = c.readLine(); err != nil { // trailing \r\n after the value
+		return rv, err
+	}
+	if _, err = c.readLine(); err != nil { // END
+		return rv, err
+	}
+
+	rv.Key = []byte(key)
+	rv.Extras = make([]byte, 4)
+	rv.Extras[0] = byte(flags >> 24)
+	rv.Extras[1] = byte(flags >> 16)
+	rv.Extras[2] = byte(flags >> 8)
+	rv.Extras[3] = byte(flags)
+	rv.Body = body
+	return rv, nil
+}
+
+// Del deletes a key.
+func (c *ASCIIClient) Del(vb uint16, key string) (rv gomemcached.MCResponse, err error) {
+	if err = c.sendLine("delete " + key); err != nil {
+		return rv, err
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return rv, err
+	}
+	switch line {
+	case "DELETED":
+		rv.Status = gomemcached.SUCCESS
+	case "NOT_FOUND":
+		rv.Status = gomemcached.KEY_ENOENT
+	default:
+		return rv, fmt.Errorf("ascii: unexpected delete response: %q", line)
+	}
+	return rv, nil
+}
+
+func (c *ASCIIClient) store(cmd string, vb uint16, key string, flags int, exp int,
+	body []byte) (rv gomemcached.MCResponse, err error) {
+
+	header := fmt.Sprintf("%s %s %d %d %d", cmd, key, flags, exp, len(body))
+	if err = c.sendLine(header); err != nil {
+		return rv, err
+	}
+	if _, err = c.writer.Write(body); err != nil {
+		return rv, err
+	}
+	if err = c.sendLine(""); err != nil {
+		return rv, err
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return rv, err
+	}
+	switch line {
+	case "STORED":
+		rv.Status = gomemcached.SUCCESS
+	case "NOT_STORED":
+		rv.Status = gomemcached.KEY_EEXISTS
+	default:
+		return rv, fmt.Errorf("ascii: unexpected %s response: %q", cmd, line)
+	}
+	return rv, nil
+}
+
+// Add a value for a key (store if not exists).
+func (c *ASCIIClient) Add(vb uint16, key string, flags int, exp int,
+	body []byte) (gomemcached.MCResponse, error) {
+	return c.store("add", vb, key, flags, exp, body)
+}
+
+// Set the value for a key.
+func (c *ASCIIClient) Set(vb uint16, key string, flags int, exp int,
+	body []byte) (gomemcached.MCResponse, error) {
+	return c.store("set", vb, key, flags, exp, body)
+}
+
+// Stats fetches stats from the server. Use "" as the stat key for
+// toplevel stats.
+func (c *ASCIIClient) Stats(key string) ([]StatValue, error) {
+	rv := []StatValue{}
+
+	cmd := "stats"
+	if key != "" {
+		cmd = "stats " + key
+	}
+	if err := c.sendLine(cmd); err != nil {
+		return rv, err
+	}
+
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return rv, err
+		}
+		if line == "END" {
+			break
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || fields[0] != "STAT" {
+			return rv, fmt.Errorf("ascii: unexpected stats response: %q", line)
+		}
+		rv = append(rv, StatValue{Key: fields[1], Val: fields[2]})
+	}
+
+	return rv, nil
+}
+
+// StatsMap gets the stats from the server as a map.
+func (c *ASCIIClient) StatsMap(key string) (map[string]string, error) {
+	rv := make(map[string]string)
+	st, err := c.Stats(key)
+	if err != nil {
+		return rv, err
+	}
+	for _, sv := range st {
+		rv[sv.Key] = sv.Val
+	}
+	return rv, nil
+}
+
+// Quit tells the server we're done talking.
+func (c *ASCIIClient) Quit() error {
+	return c.sendLine("quit")
+}
+
+func readAll(r *bufio.Reader, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := r.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
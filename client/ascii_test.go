@@ -0,0 +1,116 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dustin/gomemcached"
+)
+
+// mockASCIIServer accepts a single connection and answers the handful of
+// text commands ASCIIClient exercises, so it can be tested without a real
+// memcached server. It's deliberately minimal -- just enough line
+// parsing to drive Get/Set/Del/Stats through their real wire format.
+func mockASCIIServer(l net.Listener, store map[string]string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = line[:len(line)-1]
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case len(fields) >= 2 && fields[0] == "get":
+			if v, ok := store[fields[1]]; ok {
+				fmt.Fprintf(w, "VALUE %s 0 %d\r\n%s\r\nEND\r\n", fields[1], len(v), v)
+			} else {
+				fmt.Fprint(w, "END\r\n")
+			}
+		case len(fields) >= 2 && fields[0] == "delete":
+			if _, ok := store[fields[1]]; ok {
+				delete(store, fields[1])
+				fmt.Fprint(w, "DELETED\r\n")
+			} else {
+				fmt.Fprint(w, "NOT_FOUND\r\n")
+			}
+		case len(fields) == 5 && (fields[0] == "set" || fields[0] == "add"):
+			n := 0
+			fmt.Sscanf(fields[4], "%d", &n)
+			body := make([]byte, n)
+			readAll(r, body)
+			r.ReadString('\n') // trailing \r\n after the value
+			store[fields[1]] = string(body)
+			fmt.Fprint(w, "STORED\r\n")
+		case fields[0] == "stats":
+			fmt.Fprint(w, "STAT pid 1\r\nSTAT curr_connections 1\r\nEND\r\n")
+		case fields[0] == "quit":
+			w.Flush()
+			return
+		}
+		w.Flush()
+	}
+}
+
+func TestASCIIClientGetSetDelStats(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go mockASCIIServer(l, map[string]string{})
+
+	c, err := NewASCIIClient("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if res, err := c.Get(0, "missing"); err != nil || res.Status != gomemcached.KEY_ENOENT {
+		t.Fatalf("Get(missing) = %+v, %v, want KEY_ENOENT", res, err)
+	}
+
+	if res, err := c.Set(0, "k", 0, 0, []byte("v")); err != nil || res.Status != gomemcached.SUCCESS {
+		t.Fatalf("Set = %+v, %v, want SUCCESS", res, err)
+	}
+
+	res, err := c.Get(0, "k")
+	if err != nil {
+		t.Fatalf("Get(k) returned error: %v", err)
+	}
+	if res.Status != gomemcached.SUCCESS || string(res.Body) != "v" {
+		t.Fatalf("Get(k) = %+v, want SUCCESS/\"v\"", res)
+	}
+
+	if res, err := c.Del(0, "k"); err != nil || res.Status != gomemcached.SUCCESS {
+		t.Fatalf("Del(k) = %+v, %v, want SUCCESS", res, err)
+	}
+	if res, err := c.Del(0, "k"); err != nil || res.Status != gomemcached.KEY_ENOENT {
+		t.Fatalf("Del(k) again = %+v, %v, want KEY_ENOENT", res, err)
+	}
+
+	stats, err := c.StatsMap("")
+	if err != nil {
+		t.Fatalf("StatsMap returned error: %v", err)
+	}
+	if stats["pid"] != "1" || stats["curr_connections"] != "1" {
+		t.Fatalf("StatsMap = %+v, want pid=1/curr_connections=1", stats)
+	}
+}
@@ -0,0 +1,102 @@
+package memcached
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/dustin/gomemcached"
+)
+
+// ErrAuthRequired is returned by ConnectAuth when the server rejects the
+// credentials it was given.
+var ErrAuthRequired = errors.New("memcached: auth required")
+
+// authContinueStatus is the binary protocol's SASL "continue" status
+// (0x21), returned mid-handshake by mechanisms like CRAM-MD5 that need a
+// challenge/response round trip. gomemcached doesn't define auth-specific
+// status codes, so it's declared here instead of invented upstream.
+const authContinueStatus = gomemcached.Status(0x21)
+
+// ListMechanisms asks the server which SASL mechanisms it supports.
+func (client *Client) ListMechanisms() (string, error) {
+	var req gomemcached.MCRequest
+	req.Opcode = gomemcached.SASL_LIST_MECHS
+	req.Key = []byte{}
+	req.Extras = []byte{}
+	req.Body = []byte{}
+	res, err := client.Send(&req)
+	if err != nil {
+		return "", err
+	}
+	return string(res.Body), nil
+}
+
+// Auth performs a SASL handshake against the server using the named
+// mechanism, either "PLAIN" or "CRAM-MD5".
+func (client *Client) Auth(mechanism, user, pass string) (gomemcached.MCResponse, error) {
+	switch mechanism {
+	case "PLAIN":
+		return client.authPlain(user, pass)
+	case "CRAM-MD5":
+		return client.authCRAMMD5(user, pass)
+	}
+	var rv gomemcached.MCResponse
+	return rv, fmt.Errorf("memcached: unsupported SASL mechanism %q", mechanism)
+}
+
+func (client *Client) authPlain(user, pass string) (gomemcached.MCResponse, error) {
+	var req gomemcached.MCRequest
+	req.Opcode = gomemcached.SASL_AUTH
+	req.Key = []byte("PLAIN")
+	req.Extras = []byte{}
+	req.Body = []byte("\x00" + user + "\x00" + pass)
+	return client.Send(&req)
+}
+
+func (client *Client) authCRAMMD5(user, pass string) (rv gomemcached.MCResponse, err error) {
+	var req gomemcached.MCRequest
+	req.Opcode = gomemcached.SASL_AUTH
+	req.Key = []byte("CRAM-MD5")
+	req.Extras = []byte{}
+	req.Body = []byte{}
+	res, err := client.Send(&req)
+	if err != nil {
+		return rv, err
+	}
+	if res.Status != authContinueStatus {
+		return res, nil
+	}
+
+	mac := hmac.New(md5.New, []byte(pass))
+	mac.Write(res.Body)
+	digest := hex.EncodeToString(mac.Sum(nil))
+
+	req = gomemcached.MCRequest{}
+	req.Opcode = gomemcached.SASL_STEP
+	req.Key = []byte("CRAM-MD5")
+	req.Extras = []byte{}
+	req.Body = []byte(user + " " + digest)
+	return client.Send(&req)
+}
+
+// ConnectAuth connects to dest and immediately authenticates with the
+// given SASL mechanism before returning the Client.
+func ConnectAuth(prot, dest, mechanism, user, pass string) (*Client, error) {
+	client, err := Connect(prot, dest)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Auth(mechanism, user, pass)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if res.Status != gomemcached.SUCCESS {
+		client.Close()
+		return nil, ErrAuthRequired
+	}
+	return client, nil
+}
@@ -0,0 +1,172 @@
+package memcached
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/dustin/gomemcached"
+)
+
+// mockAuthErrorStatus mirrors the server package's authErrorStatus
+// (0x20), used here only to make mockAuthServer's responses realistic.
+const mockAuthErrorStatus = gomemcached.Status(0x20)
+
+// mockAuthReq is a SASL_AUTH/SASL_STEP request as seen by mockAuthServer.
+type mockAuthReq struct {
+	opcode gomemcached.CommandCode
+	opaque uint32
+	key    []byte
+	body   []byte
+}
+
+func readMockAuthReq(r io.Reader) (mockAuthReq, bool) {
+	hdr := make([]byte, gomemcached.HDR_LEN)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return mockAuthReq{}, false
+	}
+	keyLen := binary.BigEndian.Uint16(hdr[2:4])
+	extraLen := uint32(hdr[4])
+	totalLen := binary.BigEndian.Uint32(hdr[8:12])
+
+	req := mockAuthReq{
+		opcode: gomemcached.CommandCode(hdr[1]),
+		opaque: binary.BigEndian.Uint32(hdr[12:16]),
+	}
+	if _, err := io.ReadFull(r, make([]byte, extraLen)); err != nil {
+		return mockAuthReq{}, false
+	}
+	req.key = make([]byte, keyLen)
+	if _, err := io.ReadFull(r, req.key); err != nil {
+		return mockAuthReq{}, false
+	}
+	req.body = make([]byte, totalLen-uint32(keyLen)-extraLen)
+	if _, err := io.ReadFull(r, req.body); err != nil {
+		return mockAuthReq{}, false
+	}
+	return req, true
+}
+
+func writeMockAuthRes(w io.Writer, opcode gomemcached.CommandCode, opaque uint32, status gomemcached.Status, body []byte) {
+	hdr := make([]byte, gomemcached.HDR_LEN)
+	hdr[0] = gomemcached.RES_MAGIC
+	hdr[1] = byte(opcode)
+	binary.BigEndian.PutUint16(hdr[6:8], uint16(status))
+	binary.BigEndian.PutUint32(hdr[8:12], uint32(len(body)))
+	binary.BigEndian.PutUint32(hdr[12:16], opaque)
+	w.Write(hdr)
+	w.Write(body)
+}
+
+// mockAuthServer accepts a single connection and answers SASL_AUTH/
+// SASL_STEP the way a real Authenticator would for the given PLAIN and
+// CRAM-MD5 credentials, so Auth/ConnectAuth can be exercised without a
+// real memcached server.
+func mockAuthServer(l net.Listener, user, pass string) {
+	const challenge = "mock-cram-challenge"
+
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		req, ok := readMockAuthReq(conn)
+		if !ok {
+			return
+		}
+		switch req.opcode {
+		case gomemcached.SASL_AUTH:
+			switch string(req.key) {
+			case "PLAIN":
+				status := mockAuthErrorStatus
+				if string(req.body) == "\x00"+user+"\x00"+pass {
+					status = gomemcached.SUCCESS
+				}
+				writeMockAuthRes(conn, req.opcode, req.opaque, status, nil)
+			case "CRAM-MD5":
+				writeMockAuthRes(conn, req.opcode, req.opaque, authContinueStatus, []byte(challenge))
+			default:
+				writeMockAuthRes(conn, req.opcode, req.opaque, mockAuthErrorStatus, nil)
+			}
+		case gomemcached.SASL_STEP:
+			mac := hmac.New(md5.New, []byte(pass))
+			mac.Write([]byte(challenge))
+			want := user + " " + hex.EncodeToString(mac.Sum(nil))
+			status := mockAuthErrorStatus
+			if string(req.body) == want {
+				status = gomemcached.SUCCESS
+			}
+			writeMockAuthRes(conn, req.opcode, req.opaque, status, nil)
+		default:
+			writeMockAuthRes(conn, req.opcode, req.opaque, mockAuthErrorStatus, nil)
+		}
+	}
+}
+
+func TestAuthPlainAndCRAMMD5(t *testing.T) {
+	const user, pass = "alice", "s3cret"
+
+	for _, mech := range []string{"PLAIN", "CRAM-MD5"} {
+		t.Run(mech, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			go mockAuthServer(l, user, pass)
+
+			client, err := Connect("tcp", l.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			res, err := client.Auth(mech, user, pass)
+			if err != nil {
+				t.Fatalf("Auth(%q) returned error: %v", mech, err)
+			}
+			if res.Status != gomemcached.SUCCESS {
+				t.Fatalf("Auth(%q) = status %v, want SUCCESS", mech, res.Status)
+			}
+		})
+	}
+}
+
+func TestConnectAuthSucceeds(t *testing.T) {
+	const user, pass = "alice", "s3cret"
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go mockAuthServer(l, user, pass)
+
+	client, err := ConnectAuth("tcp", l.Addr().String(), "CRAM-MD5", user, pass)
+	if err != nil {
+		t.Fatalf("ConnectAuth returned error: %v", err)
+	}
+	client.Close()
+}
+
+func TestConnectAuthRejectsBadCredentials(t *testing.T) {
+	const user, pass = "alice", "s3cret"
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go mockAuthServer(l, user, pass)
+
+	_, err = ConnectAuth("tcp", l.Addr().String(), "PLAIN", user, "wrong-password")
+	if err != ErrAuthRequired {
+		t.Fatalf("ConnectAuth with bad credentials = %v, want ErrAuthRequired", err)
+	}
+}
@@ -0,0 +1,140 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/dustin/gomemcached"
+)
+
+// mcReply carries a single dispatched response (or the error that ended
+// the connection) to whatever call is waiting on a request's Opaque.
+type mcReply struct {
+	res gomemcached.MCResponse
+	err error
+}
+
+// nextOpaque hands out a non-zero Opaque that is unique on this
+// connection so concurrent callers never collide in client.pending.
+func (client *Client) nextOpaque() uint32 {
+	for {
+		o := atomic.AddUint32(&client.opaque, 1)
+		if o != 0 {
+			return o
+		}
+	}
+}
+
+// transmitTo writes req, assigning it an Opaque if it doesn't have one,
+// and wires that Opaque to ch so readLoop can deliver the response (or
+// responses, for things like STAT) there.
+func (client *Client) transmitTo(req *gomemcached.MCRequest, ch chan mcReply) error {
+	if req.Opaque == 0 {
+		req.Opaque = client.nextOpaque()
+	}
+	client.pending.Store(req.Opaque, ch)
+
+	client.writeMu.Lock()
+	err := transmitRequest(client.writer, req)
+	client.writeMu.Unlock()
+
+	if err != nil {
+		client.pending.Delete(req.Opaque)
+	}
+	return err
+}
+
+// Send a custom request and wait for its response.
+func (client *Client) Send(req *gomemcached.MCRequest) (gomemcached.MCResponse, error) {
+	return client.SendContext(context.Background(), req)
+}
+
+// SendContext is Send with cancellation: if ctx is done before the
+// response for req arrives, it returns ctx.Err() and leaves the
+// connection otherwise undisturbed (the response, if one eventually
+// shows up, is simply discarded).
+func (client *Client) SendContext(ctx context.Context, req *gomemcached.MCRequest) (rv gomemcached.MCResponse, err error) {
+	ch := make(chan mcReply, 1)
+	if err = client.transmitTo(req, ch); err != nil {
+		return rv, err
+	}
+	defer client.pending.Delete(req.Opaque)
+
+	select {
+	case r := <-ch:
+		return r.res, r.err
+	case <-ctx.Done():
+		return rv, ctx.Err()
+	}
+}
+
+// Transmit a request without waiting for its response. Pair it with a
+// matching Receive -- the two queue against each other in the order
+// they were called, the same way they would on an unshared connection.
+func (client *Client) Transmit(req *gomemcached.MCRequest) error {
+	ch := make(chan mcReply, 1)
+	err := client.transmitTo(req, ch)
+	if err != nil {
+		return err
+	}
+	client.recvQueueMu.Lock()
+	client.recvQueue = append(client.recvQueue, queuedReceive{opaque: req.Opaque, ch: ch})
+	client.recvQueueMu.Unlock()
+	return nil
+}
+
+// Receive the response to the oldest outstanding Transmit on this
+// Client.
+func (client *Client) Receive() (rv gomemcached.MCResponse, err error) {
+	client.recvQueueMu.Lock()
+	if len(client.recvQueue) == 0 {
+		client.recvQueueMu.Unlock()
+		return rv, errors.New("memcached: Receive with no outstanding Transmit")
+	}
+	qr := client.recvQueue[0]
+	client.recvQueue = client.recvQueue[1:]
+	client.recvQueueMu.Unlock()
+
+	r := <-qr.ch
+	client.pending.Delete(qr.opaque)
+	return r.res, r.err
+}
+
+// readLoop owns the read side of the connection: it reads one response
+// frame at a time and dispatches it to whichever Opaque is waiting.
+// One Opaque may get several responses (a STAT exchange) and many
+// Opaques may point at the same channel (a pipelined batch); either way
+// responses queue up on that channel in arrival order.
+func (client *Client) readLoop() {
+	for {
+		res, err := client.getResponse()
+		if err != nil {
+			client.failPending(err)
+			return
+		}
+
+		if v, ok := client.pending.Load(res.Opaque); ok {
+			v.(chan mcReply) <- mcReply{res: res}
+		}
+	}
+}
+
+// failPending delivers err to every request still waiting on a
+// response, which happens once, when the connection dies. GetMulti,
+// BulkSet and BulkDel register many Opaques against one shared channel,
+// and that channel's only reader stops after its first error -- so a
+// second blocking send to it here would wedge readLoop forever. Dedupe
+// by channel instead of by Opaque to guarantee at most one send apiece.
+func (client *Client) failPending(err error) {
+	signaled := make(map[chan mcReply]bool)
+	client.pending.Range(func(key, value interface{}) bool {
+		ch := value.(chan mcReply)
+		client.pending.Delete(key)
+		if !signaled[ch] {
+			signaled[ch] = true
+			ch <- mcReply{err: err}
+		}
+		return true
+	})
+}
@@ -0,0 +1,56 @@
+package memcached
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestFailPendingDedupsSharedChannels reproduces the wedge a batch call
+// leaves behind when its connection dies: GetMulti/BulkSet/BulkDel
+// register many Opaques against one shared, unbuffered channel, but that
+// channel's only reader stops after its first error. failPending must
+// send to each distinct channel at most once, or the second send blocks
+// forever with no reader left -- wedging readLoop for every other
+// request on the Client, not just the batch's.
+func TestFailPendingDedupsSharedChannels(t *testing.T) {
+	var client Client
+
+	shared := make(chan mcReply)
+	client.pending.Store(uint32(1), shared)
+	client.pending.Store(uint32(2), shared)
+	client.pending.Store(uint32(3), shared)
+
+	solo := make(chan mcReply, 1)
+	client.pending.Store(uint32(4), solo)
+
+	done := make(chan struct{})
+	go func() {
+		client.failPending(errors.New("boom"))
+		close(done)
+	}()
+
+	select {
+	case r := <-shared:
+		if r.err == nil {
+			t.Fatal("expected an error on the shared channel")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("failPending never delivered to the shared channel")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("failPending hung trying to re-signal an already-notified channel")
+	}
+
+	select {
+	case r := <-solo:
+		if r.err == nil {
+			t.Fatal("expected an error on the solo channel")
+		}
+	default:
+		t.Fatal("solo channel was never signaled")
+	}
+}
@@ -8,18 +8,45 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync"
 
 	"github.com/dustin/gomemcached"
 )
 
 const bufsize = 1024
 
-// The Client itself.
+// DefaultMaxBodyLen is the MaxBodyLen a Client gets from Connect.
+var DefaultMaxBodyLen = uint32(1 * 1e6)
+
+// The Client itself. A Client may be shared by many goroutines: each
+// outgoing request is tagged with a unique Opaque and a background
+// goroutine dispatches incoming responses back to whichever caller is
+// waiting on that Opaque.
 type Client struct {
 	conn   net.Conn
 	writer *bufio.Writer
 
 	hdrBuf []byte
+
+	// MaxBodyLen is the largest response body this Client will accept.
+	// A response claiming a larger body is rejected with a
+	// *BodyTooLarge error instead of being read into memory.
+	MaxBodyLen uint32
+
+	writeMu sync.Mutex
+
+	opaque  uint32
+	pending sync.Map // uint32 opaque -> chan mcReply
+
+	recvQueueMu sync.Mutex
+	recvQueue   []queuedReceive
+}
+
+// queuedReceive remembers which channel (and Opaque, for cleanup) a
+// Transmit call is waiting to be drained by a future Receive call.
+type queuedReceive struct {
+	opaque uint32
+	ch     chan mcReply
 }
 
 // Connect to a memcached server.
@@ -28,35 +55,30 @@ func Connect(prot, dest string) (rv *Client, err error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{
-		conn:   conn,
-		writer: bufio.NewWriterSize(conn, bufsize),
-		hdrBuf: make([]byte, gomemcached.HDR_LEN),
-	}, nil
-}
-
-// Close the connection when you're done.
-func (c *Client) Close() {
-	c.conn.Close()
+	c := &Client{
+		conn:       conn,
+		writer:     bufio.NewWriterSize(conn, bufsize),
+		hdrBuf:     make([]byte, gomemcached.HDR_LEN),
+		MaxBodyLen: DefaultMaxBodyLen,
+	}
+	go c.readLoop()
+	return c, nil
 }
 
-// Send a custom request and get the response.
-func (client *Client) Send(req *gomemcached.MCRequest) (rv gomemcached.MCResponse, err error) {
-	err = transmitRequest(client.writer, req)
-	if err != nil {
-		return
-	}
-	return client.getResponse()
+// BodyTooLarge is returned when a response's body length exceeds the
+// Client's MaxBodyLen.
+type BodyTooLarge struct {
+	Was uint32
+	Max uint32
 }
 
-// Send a request, but do not wait for a response.
-func (client *Client) Transmit(req *gomemcached.MCRequest) {
-	transmitRequest(client.writer, req)
+func (e *BodyTooLarge) Error() string {
+	return fmt.Sprintf("memcached: response body of %d bytes exceeds max of %d", e.Was, e.Max)
 }
 
-// Receive a response
-func (client *Client) Receive() (gomemcached.MCResponse, error) {
-	return client.getResponse()
+// Close the connection when you're done.
+func (c *Client) Close() {
+	c.conn.Close()
 }
 
 // Get the value for a key.
@@ -66,7 +88,6 @@ func (client *Client) Get(vb uint16, key string) (gomemcached.MCResponse, error)
 	req.VBucket = vb
 	req.Key = []byte(key)
 	req.Cas = 0
-	req.Opaque = 0
 	req.Extras = []byte{}
 	req.Body = []byte{}
 	return client.Send(&req)
@@ -79,7 +100,6 @@ func (client *Client) Del(vb uint16, key string) (gomemcached.MCResponse, error)
 	req.VBucket = vb
 	req.Key = []byte(key)
 	req.Cas = 0
-	req.Opaque = 0
 	req.Extras = []byte{}
 	req.Body = []byte{}
 	return client.Send(&req)
@@ -92,7 +112,6 @@ func (client *Client) store(opcode gomemcached.CommandCode, vb uint16,
 	req.Opcode = opcode
 	req.VBucket = vb
 	req.Cas = 0
-	req.Opaque = 0
 	req.Key = []byte(key)
 	req.Extras = []byte{0, 0, 0, 0, 0, 0, 0, 0}
 	binary.BigEndian.PutUint64(req.Extras, uint64(flags)<<32|uint64(exp))
@@ -129,28 +148,32 @@ func (client *Client) Stats(key string) ([]StatValue, error) {
 	req.Opcode = gomemcached.STAT
 	req.VBucket = 0
 	req.Cas = 0
-	req.Opaque = 918494
+	req.Opaque = client.nextOpaque()
 	req.Key = []byte(key)
 	req.Extras = []byte{}
 	req.Body = []byte{}
 
-	err := transmitRequest(client.writer, &req)
-	if err != nil {
+	// The server sends one response per stat sharing this request's
+	// Opaque, terminated by one with an empty key, so every reply is
+	// routed to the same channel rather than a fresh one per message.
+	ch := make(chan mcReply)
+	if err := client.transmitTo(&req, ch); err != nil {
 		return rv, err
 	}
+	defer client.pending.Delete(req.Opaque)
 
 	for {
-		res, err := client.getResponse()
-		if err != nil {
-			return rv, err
+		r := <-ch
+		if r.err != nil {
+			return rv, r.err
 		}
-		k := string(res.Key)
+		k := string(r.res.Key)
 		if k == "" {
 			break
 		}
 		rv = append(rv, StatValue{
 			Key: k,
-			Val: string(res.Body),
+			Val: string(r.res.Body),
 		})
 	}
 
@@ -175,7 +198,7 @@ func (client *Client) getResponse() (rv gomemcached.MCResponse, err error) {
 	if err != nil {
 		return rv, err
 	}
-	rv, err = grokHeader(client.hdrBuf)
+	rv, err = grokHeader(client.hdrBuf, client.MaxBodyLen)
 	if err != nil {
 		return rv, err
 	}
@@ -195,17 +218,20 @@ func readContents(s net.Conn, res *gomemcached.MCResponse) error {
 	return readOb(s, res.Body)
 }
 
-func grokHeader(hdrBytes []byte) (rv gomemcached.MCResponse, err error) {
+func grokHeader(hdrBytes []byte, maxBodyLen uint32) (rv gomemcached.MCResponse, err error) {
 	if hdrBytes[0] != gomemcached.RES_MAGIC {
 		return rv, errors.New(fmt.Sprintf("Bad magic: 0x%02x", hdrBytes[0]))
 	}
-	// rv.Opcode = hdrBytes[1]
+	rv.Opcode = gomemcached.CommandCode(hdrBytes[1])
 	rv.Key = make([]byte, binary.BigEndian.Uint16(hdrBytes[2:]))
 	rv.Extras = make([]byte, hdrBytes[4])
 	rv.Status = uint16(hdrBytes[7])
 	bodyLen := binary.BigEndian.Uint32(hdrBytes[8:]) - uint32(len(rv.Key)) - uint32(len(rv.Extras))
+	if bodyLen > maxBodyLen {
+		return rv, &BodyTooLarge{Was: bodyLen, Max: maxBodyLen}
+	}
 	rv.Body = make([]byte, bodyLen)
-	// rv.Opaque = binary.BigEndian.Uint32(hdrBytes[12:])
+	rv.Opaque = binary.BigEndian.Uint32(hdrBytes[12:])
 	rv.Cas = binary.BigEndian.Uint64(hdrBytes[16:])
 	return
 }
@@ -0,0 +1,69 @@
+package memcached
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/dustin/gomemcached"
+)
+
+// mockOversizedBodyServer accepts a single connection, reads one request
+// frame off it, and answers with a response header claiming a body of
+// bodyLen bytes -- large enough to trip a Client's MaxBodyLen check --
+// without ever writing that many bytes, since a Client rejects the
+// header before trying to read a body that large.
+func mockOversizedBodyServer(l net.Listener, bodyLen uint32) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	hdr := make([]byte, gomemcached.HDR_LEN)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return
+	}
+	totalLen := binary.BigEndian.Uint32(hdr[8:12])
+	rest := make([]byte, totalLen)
+	if _, err := io.ReadFull(conn, rest); err != nil {
+		return
+	}
+	opaque := binary.BigEndian.Uint32(hdr[12:16])
+	opcode := hdr[1]
+
+	res := make([]byte, gomemcached.HDR_LEN)
+	res[0] = gomemcached.RES_MAGIC
+	res[1] = opcode
+	binary.BigEndian.PutUint16(res[6:8], uint16(gomemcached.SUCCESS))
+	binary.BigEndian.PutUint32(res[8:12], bodyLen)
+	binary.BigEndian.PutUint32(res[12:16], opaque)
+	conn.Write(res)
+}
+
+func TestClientRejectsOversizedBody(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go mockOversizedBodyServer(l, 10000)
+
+	client, err := Connect("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.MaxBodyLen = 16
+
+	_, err = client.Get(0, "k")
+	var tooLarge *BodyTooLarge
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Get returned %v (%T), want *BodyTooLarge", err, err)
+	}
+	if tooLarge.Was != 10000 || tooLarge.Max != 16 {
+		t.Fatalf("BodyTooLarge = %+v, want Was=10000/Max=16", tooLarge)
+	}
+}
@@ -0,0 +1,165 @@
+package memcached
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dustin/gomemcached"
+)
+
+// GetMulti fetches many keys in a single round trip. It pipelines a
+// GETKQ (quiet get-with-key) per key followed by a terminating NOOP,
+// then drains responses until the NOOP's opaque comes back; misses are
+// silently skipped since GETKQ never responds to them. Every request in
+// the batch shares one reply channel so responses are seen in the order
+// they actually arrive on the wire.
+func (client *Client) GetMulti(vb uint16, keys []string) (map[string][]byte, error) {
+	rv := make(map[string][]byte)
+	if len(keys) == 0 {
+		return rv, nil
+	}
+
+	ch := make(chan mcReply)
+	byOpaque := make(map[uint32]string, len(keys))
+	var opaques []uint32
+	defer func() { client.dropOpaques(opaques) }()
+
+	for _, k := range keys {
+		req := gomemcached.MCRequest{
+			Opcode:  gomemcached.GETKQ,
+			VBucket: vb,
+			Opaque:  client.nextOpaque(),
+			Key:     []byte(k),
+			Extras:  []byte{},
+			Body:    []byte{},
+		}
+		byOpaque[req.Opaque] = k
+		opaques = append(opaques, req.Opaque)
+		if err := client.transmitTo(&req, ch); err != nil {
+			return rv, err
+		}
+	}
+
+	noopOpaque := client.nextOpaque()
+	opaques = append(opaques, noopOpaque)
+	if err := client.transmitTo(&gomemcached.MCRequest{
+		Opcode: gomemcached.NOOP,
+		Opaque: noopOpaque,
+		Key:    []byte{},
+		Extras: []byte{},
+		Body:   []byte{},
+	}, ch); err != nil {
+		return rv, err
+	}
+
+	for {
+		r := <-ch
+		if r.err != nil {
+			return rv, r.err
+		}
+		if r.res.Opaque == noopOpaque {
+			return rv, nil
+		}
+		if k, ok := byOpaque[r.res.Opaque]; ok && r.res.Status == gomemcached.SUCCESS {
+			rv[k] = r.res.Body
+		}
+	}
+}
+
+// BulkSet stores many key/value pairs in a single round trip using SETQ
+// pipelining, which only responds on error.
+func (client *Client) BulkSet(vb uint16, items map[string][]byte, flags int, exp int) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	ch := make(chan mcReply)
+	var opaques []uint32
+	defer func() { client.dropOpaques(opaques) }()
+
+	for key, body := range items {
+		var req gomemcached.MCRequest
+		req.Opcode = gomemcached.SETQ
+		req.VBucket = vb
+		req.Opaque = client.nextOpaque()
+		req.Key = []byte(key)
+		req.Extras = make([]byte, 8)
+		binary.BigEndian.PutUint64(req.Extras, uint64(flags)<<32|uint64(exp))
+		req.Body = body
+		opaques = append(opaques, req.Opaque)
+		if err := client.transmitTo(&req, ch); err != nil {
+			return err
+		}
+	}
+
+	return client.drainQuiet(ch, &opaques)
+}
+
+// BulkDel deletes many keys in a single round trip using DELETEQ
+// pipelining, which only responds on error.
+func (client *Client) BulkDel(vb uint16, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	ch := make(chan mcReply)
+	var opaques []uint32
+	defer func() { client.dropOpaques(opaques) }()
+
+	for _, key := range keys {
+		req := gomemcached.MCRequest{
+			Opcode:  gomemcached.DELETEQ,
+			VBucket: vb,
+			Opaque:  client.nextOpaque(),
+			Key:     []byte(key),
+			Extras:  []byte{},
+			Body:    []byte{},
+		}
+		opaques = append(opaques, req.Opaque)
+		if err := client.transmitTo(&req, ch); err != nil {
+			return err
+		}
+	}
+
+	return client.drainQuiet(ch, &opaques)
+}
+
+// drainQuiet sends a terminating NOOP on ch's batch (recording its
+// Opaque into *opaques for the caller's cleanup) and reads responses
+// until it comes back, surfacing the first error response (if any) seen
+// along the way.
+func (client *Client) drainQuiet(ch chan mcReply, opaques *[]uint32) error {
+	noopOpaque := client.nextOpaque()
+	*opaques = append(*opaques, noopOpaque)
+	if err := client.transmitTo(&gomemcached.MCRequest{
+		Opcode: gomemcached.NOOP,
+		Opaque: noopOpaque,
+		Key:    []byte{},
+		Extras: []byte{},
+		Body:   []byte{},
+	}, ch); err != nil {
+		return err
+	}
+
+	var firstErr error
+	for {
+		r := <-ch
+		if r.err != nil {
+			return r.err
+		}
+		if r.res.Opaque == noopOpaque {
+			return firstErr
+		}
+		if r.res.Status != gomemcached.SUCCESS && firstErr == nil {
+			firstErr = fmt.Errorf("memcached: bulk op failed (opaque %d, status %d)", r.res.Opaque, r.res.Status)
+		}
+	}
+}
+
+// dropOpaques removes a batch's reply-channel registrations once the
+// batch is done with them.
+func (client *Client) dropOpaques(opaques []uint32) {
+	for _, o := range opaques {
+		client.pending.Delete(o)
+	}
+}
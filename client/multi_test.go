@@ -0,0 +1,90 @@
+package memcached
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/dustin/gomemcached"
+)
+
+// mockMultiServer accepts a single connection and answers the pipelined
+// quiet opcodes GetMulti/BulkSet/BulkDel emit (GETKQ/SETQ/DELETEQ,
+// followed by a terminating NOOP), reusing auth_test.go's
+// readMockAuthReq/writeMockAuthRes wire helpers since the framing is the
+// same regardless of opcode.
+func mockMultiServer(l net.Listener, store map[string][]byte) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		req, ok := readMockAuthReq(conn)
+		if !ok {
+			return
+		}
+		switch req.opcode {
+		case gomemcached.GETKQ:
+			if v, found := store[string(req.key)]; found {
+				writeMockAuthRes(conn, req.opcode, req.opaque, gomemcached.SUCCESS, v)
+			}
+			// A miss gets no response -- that's what makes it quiet.
+		case gomemcached.SETQ:
+			store[string(req.key)] = append([]byte{}, req.body...)
+		case gomemcached.DELETEQ:
+			delete(store, string(req.key))
+		case gomemcached.NOOP:
+			writeMockAuthRes(conn, req.opcode, req.opaque, gomemcached.SUCCESS, nil)
+		}
+	}
+}
+
+func TestGetMultiBulkSetBulkDel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	store := map[string][]byte{"a": []byte("1"), "b": []byte("2")}
+	go mockMultiServer(l, store)
+
+	client, err := Connect("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	got, err := client.GetMulti(0, []string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatalf("GetMulti returned error: %v", err)
+	}
+	want := map[string][]byte{"a": []byte("1"), "b": []byte("2")}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetMulti = %+v, want %+v", got, want)
+	}
+
+	if err := client.BulkSet(0, map[string][]byte{"c": []byte("3")}, 0, 0); err != nil {
+		t.Fatalf("BulkSet returned error: %v", err)
+	}
+	got, err = client.GetMulti(0, []string{"c"})
+	if err != nil {
+		t.Fatalf("GetMulti after BulkSet returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string][]byte{"c": []byte("3")}) {
+		t.Fatalf("GetMulti after BulkSet = %+v, want c=3", got)
+	}
+
+	if err := client.BulkDel(0, []string{"a", "c"}); err != nil {
+		t.Fatalf("BulkDel returned error: %v", err)
+	}
+	got, err = client.GetMulti(0, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("GetMulti after BulkDel returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string][]byte{"b": []byte("2")}) {
+		t.Fatalf("GetMulti after BulkDel = %+v, want only b=2", got)
+	}
+}
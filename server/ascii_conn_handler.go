@@ -0,0 +1,172 @@
+package memcached
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/gomemcached"
+)
+
+// HandleASCIIIO handles the ASCII (text) protocol on the given stream,
+// dispatching decoded requests onto reqChannel the same way HandleIO does
+// for the binary protocol.
+//
+// The ASCII protocol has no SASL commands, so a connection using it can
+// never complete the handshake Authenticator requires. Setting
+// Authenticator therefore makes every ASCII request other than "quit"
+// fail closed with "CLIENT_ERROR authentication required" instead of
+// silently bypassing auth the way forwarding them unchecked would.
+func HandleASCIIIO(s io.ReadWriteCloser, reqChannel chan gomemcached.MCRequest) {
+	defer s.Close()
+	r := bufio.NewReader(s)
+	w := bufio.NewWriter(s)
+	authenticated := Authenticator == nil
+	for handleASCIIMessage(r, w, reqChannel, &authenticated) {
+	}
+}
+
+func handleASCIIMessage(r *bufio.Reader, w *bufio.Writer,
+	reqChannel chan gomemcached.MCRequest, authenticated *bool) (ret bool) {
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	line = strings.TrimRight(line, "\r\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+
+	req, err := asciiRequest(r, fields)
+	if err != nil {
+		writeASCIILine(w, "ERROR")
+		return true
+	}
+	if req.Opcode == gomemcached.QUIT {
+		return false
+	}
+
+	if Authenticator != nil && !*authenticated {
+		writeASCIILine(w, "CLIENT_ERROR authentication required")
+		return true
+	}
+
+	req.ResponseChannel = make(chan gomemcached.MCResponse)
+	reqChannel <- req
+	res := <-req.ResponseChannel
+	if res.Fatal {
+		return false
+	}
+
+	writeASCIIResponse(w, req, res)
+	return true
+}
+
+func asciiRequest(r *bufio.Reader, fields []string) (rv gomemcached.MCRequest, err error) {
+	switch fields[0] {
+	case "get":
+		if len(fields) != 2 {
+			return rv, fmt.Errorf("ascii: get takes exactly one key")
+		}
+		rv.Opcode = gomemcached.GET
+		rv.Key = []byte(fields[1])
+		rv.Extras = []byte{}
+		rv.Body = []byte{}
+	case "delete":
+		if len(fields) != 2 {
+			return rv, fmt.Errorf("ascii: delete takes exactly one key")
+		}
+		rv.Opcode = gomemcached.DELETE
+		rv.Key = []byte(fields[1])
+		rv.Extras = []byte{}
+		rv.Body = []byte{}
+	case "set", "add":
+		if len(fields) != 5 {
+			return rv, fmt.Errorf("ascii: %s takes key flags exptime bytes", fields[0])
+		}
+		flags, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return rv, err
+		}
+		exp, err := strconv.ParseUint(fields[3], 10, 32)
+		if err != nil {
+			return rv, err
+		}
+		n, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return rv, err
+		}
+		body := make([]byte, n)
+		if _, err = io.ReadFull(r, body); err != nil {
+			return rv, err
+		}
+		if _, err = r.ReadString('\n'); err != nil { // trailing \r\n
+			return rv, err
+		}
+
+		if fields[0] == "set" {
+			rv.Opcode = gomemcached.SET
+		} else {
+			rv.Opcode = gomemcached.ADD
+		}
+		rv.Key = []byte(fields[1])
+		rv.Extras = make([]byte, 8)
+		binary.BigEndian.PutUint64(rv.Extras, uint64(flags)<<32|exp)
+		rv.Body = body
+	case "stats":
+		rv.Opcode = gomemcached.STAT
+		rv.Extras = []byte{}
+		rv.Body = []byte{}
+		if len(fields) > 1 {
+			rv.Key = []byte(fields[1])
+		}
+	case "quit":
+		rv.Opcode = gomemcached.QUIT
+	default:
+		return rv, fmt.Errorf("ascii: unknown command %q", fields[0])
+	}
+	return rv, nil
+}
+
+func writeASCIIResponse(w *bufio.Writer, req gomemcached.MCRequest, res gomemcached.MCResponse) {
+	switch req.Opcode {
+	case gomemcached.GET:
+		if res.Status != gomemcached.SUCCESS {
+			writeASCIILine(w, "END")
+			return
+		}
+		flags := binary.BigEndian.Uint32(res.Extras)
+		writeASCIILine(w, fmt.Sprintf("VALUE %s %d %d", req.Key, flags, len(res.Body)))
+		w.Write(res.Body)
+		writeASCIILine(w, "")
+		writeASCIILine(w, "END")
+	case gomemcached.SET, gomemcached.ADD:
+		if res.Status == gomemcached.SUCCESS {
+			writeASCIILine(w, "STORED")
+		} else {
+			writeASCIILine(w, "NOT_STORED")
+		}
+	case gomemcached.DELETE:
+		if res.Status == gomemcached.SUCCESS {
+			writeASCIILine(w, "DELETED")
+		} else {
+			writeASCIILine(w, "NOT_FOUND")
+		}
+	case gomemcached.STAT:
+		if k := string(res.Key); k != "" {
+			writeASCIILine(w, fmt.Sprintf("STAT %s %s", k, res.Body))
+		}
+		writeASCIILine(w, "END")
+	}
+	w.Flush()
+}
+
+func writeASCIILine(w *bufio.Writer, line string) {
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
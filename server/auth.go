@@ -0,0 +1,69 @@
+package memcached
+
+import "github.com/dustin/gomemcached"
+
+// Auth-specific status codes from the binary protocol spec. gomemcached
+// only defines Status constants for the data commands, not these, so
+// they're declared here rather than invented as gomemcached symbols.
+const (
+	authErrorStatus    = gomemcached.Status(0x20)
+	authContinueStatus = gomemcached.Status(0x21)
+)
+
+// AuthResult is returned by an Authenticator to describe the outcome of a
+// SASL_AUTH or SASL_STEP exchange.
+type AuthResult struct {
+	// Status to send back to the client: gomemcached.SUCCESS,
+	// authContinueStatus or authErrorStatus.
+	Status gomemcached.Status
+	// Body to send back, e.g. a CRAM-MD5 challenge on authContinueStatus.
+	Body []byte
+}
+
+// Authenticator, when set, makes HandleIO answer SASL_LIST_MECHS itself
+// and route SASL_AUTH/SASL_STEP requests here instead of onto
+// reqChannel. While set, every other request is rejected with
+// authErrorStatus until an exchange on this connection returns
+// gomemcached.SUCCESS. Leave it nil, the default, to forward SASL
+// opcodes like any other request and skip auth enforcement entirely.
+var Authenticator func(opcode gomemcached.CommandCode, mechanism string, body []byte) AuthResult
+
+// SASLMechanisms lists the mechanisms advertised in response to a
+// SASL_LIST_MECHS request.
+var SASLMechanisms = "PLAIN CRAM-MD5"
+
+// authGate inspects req when authenticator is set: it answers
+// SASL_LIST_MECHS itself with mechanisms, routes SASL_AUTH/SASL_STEP to
+// authenticator and updates *authenticated, and rejects every other
+// opcode with authErrorStatus until an exchange returns
+// gomemcached.SUCCESS. handled reports whether res is the full response
+// callers should send back instead of dispatching req any further.
+// authenticator == nil makes this a no-op, so callers pay nothing when
+// auth isn't configured. HandleIO/handleMessage pass the Authenticator
+// and SASLMechanisms globals; Server passes its own fields of the same
+// name, so two Servers in one process can run independent auth policies.
+func authGate(req gomemcached.MCRequest, authenticator func(opcode gomemcached.CommandCode, mechanism string, body []byte) AuthResult, mechanisms string, authenticated *bool) (res gomemcached.MCResponse, handled bool) {
+	if authenticator == nil {
+		return res, false
+	}
+
+	switch req.Opcode {
+	case gomemcached.SASL_LIST_MECHS:
+		return gomemcached.MCResponse{
+			Status: gomemcached.SUCCESS,
+			Body:   []byte(mechanisms),
+		}, true
+	case gomemcached.SASL_AUTH, gomemcached.SASL_STEP:
+		ar := authenticator(req.Opcode, string(req.Key), req.Body)
+		if ar.Status == gomemcached.SUCCESS {
+			*authenticated = true
+		}
+		return gomemcached.MCResponse{Status: ar.Status, Body: ar.Body}, true
+	}
+
+	if !*authenticated {
+		return gomemcached.MCResponse{Status: authErrorStatus}, true
+	}
+
+	return res, false
+}
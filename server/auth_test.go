@@ -0,0 +1,134 @@
+package memcached
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/dustin/gomemcached"
+)
+
+func encodeMockRequest(opcode gomemcached.CommandCode, key, body []byte) []byte {
+	buf := make([]byte, gomemcached.HDR_LEN+len(key)+len(body))
+	buf[0] = gomemcached.REQ_MAGIC
+	buf[1] = byte(opcode)
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(key)))
+	binary.BigEndian.PutUint32(buf[8:12], uint32(len(key)+len(body)))
+	copy(buf[gomemcached.HDR_LEN:], key)
+	copy(buf[gomemcached.HDR_LEN+len(key):], body)
+	return buf
+}
+
+func decodeMockResponse(t *testing.T, data []byte) gomemcached.MCResponse {
+	t.Helper()
+	if len(data) < gomemcached.HDR_LEN {
+		t.Fatalf("short response: %d bytes", len(data))
+	}
+	keyLen := uint32(binary.BigEndian.Uint16(data[2:4]))
+	extraLen := uint32(data[4])
+	totalLen := binary.BigEndian.Uint32(data[8:12])
+	body := data[gomemcached.HDR_LEN+extraLen+keyLen:]
+	if uint32(len(body)) != totalLen-keyLen-extraLen {
+		t.Fatalf("body length mismatch: got %d bytes, header claims %d", len(body), totalLen-keyLen-extraLen)
+	}
+	return gomemcached.MCResponse{
+		Status: gomemcached.Status(binary.BigEndian.Uint16(data[6:8])),
+		Body:   body,
+	}
+}
+
+// withAuthenticator installs an Authenticator that accepts PLAIN
+// user/pass and restores the previous one when the test finishes.
+func withAuthenticator(t *testing.T, user, pass string) {
+	t.Helper()
+	prev := Authenticator
+	Authenticator = func(opcode gomemcached.CommandCode, mechanism string, body []byte) AuthResult {
+		if mechanism == "PLAIN" && string(body) == "\x00"+user+"\x00"+pass {
+			return AuthResult{Status: gomemcached.SUCCESS}
+		}
+		return AuthResult{Status: authErrorStatus}
+	}
+	t.Cleanup(func() { Authenticator = prev })
+}
+
+func TestHandleMessageRejectsUnauthenticated(t *testing.T) {
+	withAuthenticator(t, "alice", "s3cret")
+
+	reqChannel := make(chan gomemcached.MCRequest, 1)
+	authenticated := false
+	var out bytes.Buffer
+
+	in := bytes.NewReader(encodeMockRequest(gomemcached.GET, []byte("k"), nil))
+	if !handleMessage(in, &out, reqChannel, &authenticated) {
+		t.Fatal("handleMessage returned false for a rejected request")
+	}
+
+	res := decodeMockResponse(t, out.Bytes())
+	if res.Status != authErrorStatus {
+		t.Fatalf("status = %v, want authErrorStatus", res.Status)
+	}
+	select {
+	case <-reqChannel:
+		t.Fatal("unauthenticated request reached reqChannel")
+	default:
+	}
+}
+
+func TestHandleMessageSASLHandshake(t *testing.T) {
+	const user, pass = "alice", "s3cret"
+	withAuthenticator(t, user, pass)
+
+	reqChannel := make(chan gomemcached.MCRequest, 1)
+	authenticated := false
+	var out bytes.Buffer
+
+	// SASL_LIST_MECHS is answered directly, without touching auth state.
+	in := bytes.NewReader(encodeMockRequest(gomemcached.SASL_LIST_MECHS, nil, nil))
+	if !handleMessage(in, &out, reqChannel, &authenticated) {
+		t.Fatal("handleMessage returned false for SASL_LIST_MECHS")
+	}
+	res := decodeMockResponse(t, out.Bytes())
+	if string(res.Body) != SASLMechanisms {
+		t.Fatalf("SASL_LIST_MECHS body = %q, want %q", res.Body, SASLMechanisms)
+	}
+	if authenticated {
+		t.Fatal("SASL_LIST_MECHS should not flip authenticated")
+	}
+
+	// A bad PLAIN attempt is rejected and leaves authenticated false.
+	out.Reset()
+	in = bytes.NewReader(encodeMockRequest(gomemcached.SASL_AUTH, []byte("PLAIN"), []byte("\x00"+user+"\x00wrong")))
+	handleMessage(in, &out, reqChannel, &authenticated)
+	if res := decodeMockResponse(t, out.Bytes()); res.Status != authErrorStatus {
+		t.Fatalf("bad PLAIN attempt status = %v, want authErrorStatus", res.Status)
+	}
+	if authenticated {
+		t.Fatal("bad PLAIN attempt flipped authenticated")
+	}
+
+	// The correct credentials succeed and flip authenticated.
+	out.Reset()
+	in = bytes.NewReader(encodeMockRequest(gomemcached.SASL_AUTH, []byte("PLAIN"), []byte("\x00"+user+"\x00"+pass)))
+	handleMessage(in, &out, reqChannel, &authenticated)
+	if res := decodeMockResponse(t, out.Bytes()); res.Status != gomemcached.SUCCESS {
+		t.Fatalf("good PLAIN attempt status = %v, want SUCCESS", res.Status)
+	}
+	if !authenticated {
+		t.Fatal("successful handshake did not flip authenticated")
+	}
+
+	// Now a GET is dispatched onto reqChannel like any other request.
+	out.Reset()
+	in = bytes.NewReader(encodeMockRequest(gomemcached.GET, []byte("k"), nil))
+	done := make(chan bool, 1)
+	go func() { done <- handleMessage(in, &out, reqChannel, &authenticated) }()
+
+	req := <-reqChannel
+	req.ResponseChannel <- gomemcached.MCResponse{Status: gomemcached.SUCCESS, Body: []byte("v")}
+	if !<-done {
+		t.Fatal("handleMessage returned false for an authenticated request")
+	}
+	if res := decodeMockResponse(t, out.Bytes()); res.Status != gomemcached.SUCCESS || string(res.Body) != "v" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+}
@@ -3,12 +3,11 @@ package memcached
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
 
-	"github.com/dustin/go-humanize"
 	"github.com/dustin/gomemcached"
 )
 
@@ -27,16 +26,24 @@ func (b BadMagic) Error() string {
 
 func HandleIO(s io.ReadWriteCloser, reqChannel chan gomemcached.MCRequest) {
 	defer s.Close()
-	for handleMessage(s, s, reqChannel) {
+	authenticated := Authenticator == nil
+	for handleMessage(s, s, reqChannel, &authenticated) {
 	}
 }
 
-func handleMessage(r io.Reader, w io.Writer, reqChannel chan gomemcached.MCRequest) (ret bool) {
+func handleMessage(r io.Reader, w io.Writer, reqChannel chan gomemcached.MCRequest,
+	authenticated *bool) (ret bool) {
+
 	req, err := ReadPacket(r)
 	if err != nil {
 		return
 	}
 
+	if res, handled := authGate(req, Authenticator, SASLMechanisms, authenticated); handled {
+		transmitResponse(w, req, res)
+		return true
+	}
+
 	req.ResponseChannel = make(chan gomemcached.MCResponse)
 	reqChannel <- req
 	res := <-req.ResponseChannel
@@ -48,55 +55,31 @@ func handleMessage(r io.Reader, w io.Writer, reqChannel chan gomemcached.MCReque
 	return
 }
 
+// ReadPacket reads a full request, including its body, into memory. It's
+// a thin wrapper around ReadPacketStream for callers that don't need to
+// stream large values.
 func ReadPacket(r io.Reader) (rv gomemcached.MCRequest, err error) {
-	hdrBytes := make([]byte, gomemcached.HDR_LEN)
-	bytesRead, err := io.ReadFull(r, hdrBytes)
+	hdr, body, err := ReadPacketStream(r)
 	if err != nil {
-		return
-	}
-	if bytesRead != gomemcached.HDR_LEN {
-		panic("Expected to read full and didn't")
+		return rv, err
 	}
 
-	rv, err = grokHeader(hdrBytes)
-	if err != nil {
-		return
-	}
-
-	err = readContents(r, &rv)
-	return
-}
-
-func readContents(s io.Reader, req *gomemcached.MCRequest) (err error) {
-	err = readOb(s, req.Extras)
-	if err != nil {
-		return err
-	}
-	err = readOb(s, req.Key)
-	if err != nil {
-		return err
-	}
-	return readOb(s, req.Body)
+	rv.Opcode = hdr.Opcode
+	rv.VBucket = hdr.VBucket
+	rv.Opaque = hdr.Opaque
+	rv.Cas = hdr.Cas
+	rv.Extras = hdr.Extras
+	rv.Key = hdr.Key
+	rv.Body = make([]byte, hdr.BodyLen)
+	_, err = io.ReadFull(body, rv.Body)
+	return rv, err
 }
 
+// transmitResponse writes a full response, including its body, in one
+// shot. It's a thin wrapper around WriteResponseStream for callers that
+// already have the whole body in memory.
 func transmitResponse(s io.Writer, req gomemcached.MCRequest, res gomemcached.MCResponse) {
-	o := bufio.NewWriter(s)
-	writeByte(o, gomemcached.RES_MAGIC)
-	writeByte(o, byte(req.Opcode))
-	writeUint16(o, uint16(len(res.Key)))
-	writeByte(o, uint8(len(res.Extras)))
-	writeByte(o, 0)
-	writeUint16(o, res.Status)
-	writeUint32(o, uint32(len(res.Body))+
-		uint32(len(res.Key))+
-		uint32(len(res.Extras)))
-	writeUint32(o, req.Opaque)
-	writeUint64(o, res.Cas)
-	writeBytes(o, res.Extras)
-	writeBytes(o, res.Key)
-	writeBytes(o, res.Body)
-	o.Flush()
-	return
+	WriteResponseStream(s, req, res, uint32(len(res.Body)), bytes.NewReader(res.Body))
 }
 
 func writeBytes(s *bufio.Writer, data []byte) error {
@@ -144,22 +127,3 @@ func readOb(s io.Reader, buf []byte) error {
 	return err
 }
 
-func grokHeader(hdrBytes []byte) (rv gomemcached.MCRequest, err error) {
-	if hdrBytes[0] != gomemcached.REQ_MAGIC {
-		return rv, &BadMagic{was: hdrBytes[0]}
-	}
-	rv.Opcode = gomemcached.CommandCode(hdrBytes[1])
-	rv.Key = make([]byte, binary.BigEndian.Uint16(hdrBytes[2:]))
-	rv.Extras = make([]byte, hdrBytes[4])
-	// Vbucket at 6:7
-	rv.VBucket = binary.BigEndian.Uint16(hdrBytes[6:])
-	bodyLen := binary.BigEndian.Uint32(hdrBytes[8:]) - uint32(len(rv.Key)) - uint32(len(rv.Extras))
-	if bodyLen > MaxBodyLen {
-		return rv, errors.New(fmt.Sprintf("%d is too big (max %s)",
-			bodyLen, humanize.Bytes(uint64(MaxBodyLen))))
-	}
-	rv.Body = make([]byte, bodyLen)
-	rv.Opaque = binary.BigEndian.Uint32(hdrBytes[12:])
-	rv.Cas = binary.BigEndian.Uint64(hdrBytes[16:])
-	return rv, nil
-}
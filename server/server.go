@@ -0,0 +1,251 @@
+package memcached
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/gomemcached"
+)
+
+// Stats is a snapshot of the counters a Server tracks.
+type Stats struct {
+	BytesIn  uint64
+	BytesOut uint64
+	Ops      map[gomemcached.CommandCode]uint64
+}
+
+type job struct {
+	req  gomemcached.MCRequest
+	done chan gomemcached.MCResponse
+}
+
+// Server accepts connections from a net.Listener and dispatches decoded
+// requests to Handler through a fixed pool of worker goroutines, with
+// per-connection read/write deadlines, graceful shutdown and basic
+// byte/op counters -- the things HandleIO's bare reqChannel leaves to
+// the caller. Like HandleIO, it honors Authenticator when one is set,
+// gating every opcode but the SASL ones behind a successful handshake,
+// but Authenticator and SASLMechanisms are fields on Server rather than
+// the package-level globals HandleIO reads, so multiple Servers in one
+// process can enforce independent auth policies.
+type Server struct {
+	// Handler computes the response for a decoded request. It is
+	// called from a worker goroutine, never from the connection's own
+	// goroutine, so it must not assume anything about ordering between
+	// connections.
+	Handler func(gomemcached.MCRequest) gomemcached.MCResponse
+
+	// ReadTimeout/WriteTimeout, applied before every read and write on
+	// every accepted connection via SetReadDeadline/SetWriteDeadline.
+	// Zero means no deadline.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// Authenticator, when set, gates every request but SASL_LIST_MECHS/
+	// SASL_AUTH/SASL_STEP behind a successful handshake, the same way
+	// the package-level Authenticator does for HandleIO. Leave it nil,
+	// the default, to skip auth enforcement entirely.
+	Authenticator func(opcode gomemcached.CommandCode, mechanism string, body []byte) AuthResult
+
+	// SASLMechanisms lists the mechanisms this Server advertises in
+	// response to a SASL_LIST_MECHS request. Defaults to the same value
+	// as the package-level SASLMechanisms.
+	SASLMechanisms string
+
+	jobs chan job
+	wg   sync.WaitGroup
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closing  chan struct{}
+	closeOne sync.Once
+	jobsOne  sync.Once
+
+	bytesIn  uint64
+	bytesOut uint64
+	opsMu    sync.Mutex
+	ops      map[gomemcached.CommandCode]uint64
+}
+
+// NewServer creates a Server with the given number of worker goroutines
+// (at least 1) running handler.
+func NewServer(handler func(gomemcached.MCRequest) gomemcached.MCResponse, workers int) *Server {
+	if workers < 1 {
+		workers = 1
+	}
+	s := &Server{
+		Handler:        handler,
+		SASLMechanisms: SASLMechanisms,
+		jobs:           make(chan job),
+		conns:          make(map[net.Conn]struct{}),
+		closing:        make(chan struct{}),
+		ops:            make(map[gomemcached.CommandCode]uint64),
+	}
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+func (s *Server) work() {
+	for j := range s.jobs {
+		j.done <- s.Handler(j.req)
+	}
+}
+
+// Serve accepts connections from l until it errors or Shutdown is
+// called, handing each one to its own goroutine. It returns nil if the
+// listener was closed by Shutdown, and the Accept error otherwise.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-s.closing:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// Shutdown stops accepting new connections, closes every connection
+// currently being served, and waits for their goroutines to finish. It
+// returns ctx.Err() if ctx is done first, but s.jobs is only ever closed
+// once every serveConn goroutine has actually returned: a serveConn still
+// blocked in `case s.jobs <- job{...}: case <-s.closing:` would otherwise
+// race a concurrent close(s.jobs) and could panic sending on a closed
+// channel. That close happens in the background regardless of which
+// branch of the select below fires, so the worker pool started by
+// NewServer never leaks even when ctx expires before the connections
+// finish draining -- Shutdown just stops waiting for it. It's safe to
+// call more than once, including concurrently.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closeOne.Do(func() { close(s.closing) })
+
+	s.mu.Lock()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		s.jobsOne.Do(func() { close(s.jobs) })
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of this Server's byte and per-opcode
+// counters.
+func (s *Server) Stats() Stats {
+	s.opsMu.Lock()
+	ops := make(map[gomemcached.CommandCode]uint64, len(s.ops))
+	for op, n := range s.ops {
+		ops[op] = n
+	}
+	s.opsMu.Unlock()
+
+	return Stats{
+		BytesIn:  atomic.LoadUint64(&s.bytesIn),
+		BytesOut: atomic.LoadUint64(&s.bytesOut),
+		Ops:      ops,
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	authenticated := s.Authenticator == nil
+
+	for {
+		if s.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+		}
+		req, err := ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		s.countOp(req.Opcode)
+		atomic.AddUint64(&s.bytesIn, requestLen(req))
+
+		if res, handled := authGate(req, s.Authenticator, s.SASLMechanisms, &authenticated); handled {
+			if s.WriteTimeout > 0 {
+				conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+			}
+			transmitResponse(conn, req, res)
+			atomic.AddUint64(&s.bytesOut, responseLen(res))
+			continue
+		}
+
+		done := make(chan gomemcached.MCResponse, 1)
+		select {
+		case s.jobs <- job{req: req, done: done}:
+		case <-s.closing:
+			return
+		}
+
+		var res gomemcached.MCResponse
+		select {
+		case res = <-done:
+		case <-s.closing:
+			return
+		}
+		if res.Fatal {
+			return
+		}
+
+		if s.WriteTimeout > 0 {
+			conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+		}
+		transmitResponse(conn, req, res)
+		atomic.AddUint64(&s.bytesOut, responseLen(res))
+	}
+}
+
+func (s *Server) countOp(op gomemcached.CommandCode) {
+	s.opsMu.Lock()
+	s.ops[op]++
+	s.opsMu.Unlock()
+}
+
+func requestLen(req gomemcached.MCRequest) uint64 {
+	return uint64(gomemcached.HDR_LEN + len(req.Extras) + len(req.Key) + len(req.Body))
+}
+
+func responseLen(res gomemcached.MCResponse) uint64 {
+	return uint64(gomemcached.HDR_LEN + len(res.Extras) + len(res.Key) + len(res.Body))
+}
@@ -0,0 +1,198 @@
+package memcached
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dustin/gomemcached"
+)
+
+// readMockResponse reads one full response frame off conn, header and
+// body, for tests that talk to a Server over a real connection.
+func readMockResponse(t *testing.T, conn net.Conn) []byte {
+	t.Helper()
+	hdr := make([]byte, gomemcached.HDR_LEN)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		t.Fatalf("reading response header: %v", err)
+	}
+	totalLen := binary.BigEndian.Uint32(hdr[8:12])
+	rest := make([]byte, totalLen)
+	if _, err := io.ReadFull(conn, rest); err != nil {
+		t.Fatalf("reading response body: %v", err)
+	}
+	return append(hdr, rest...)
+}
+
+func assertJobsClosed(t *testing.T, s *Server) {
+	t.Helper()
+	select {
+	case _, ok := <-s.jobs:
+		if ok {
+			t.Fatal("s.jobs was not closed")
+		}
+	default:
+		t.Fatal("s.jobs was not closed")
+	}
+}
+
+func TestShutdownIdempotent(t *testing.T) {
+	s := NewServer(func(req gomemcached.MCRequest) gomemcached.MCResponse {
+		return gomemcached.MCResponse{}
+	}, 2)
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("first Shutdown returned %v", err)
+	}
+	// A second Shutdown call (e.g. a signal handler firing twice) must
+	// not panic by re-closing s.closing or s.jobs.
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("second Shutdown returned %v", err)
+	}
+	assertJobsClosed(t, s)
+}
+
+// TestShutdownJobsCloseWaitsForOutstandingConns guards against the race a
+// reviewer reproduced at ~50% frequency in an earlier version of
+// Shutdown: closing s.jobs as soon as ctx fires, rather than once every
+// serveConn goroutine s.wg is tracking has actually returned, could race
+// a live "case s.jobs <- job{...}" send in serveConn with a concurrent
+// close(s.jobs) and panic. A real serveConn goroutine is accounted for
+// here by a stand-in that holds s.wg open until blocker is closed, which
+// is deterministic; reproducing the actual send/close race over a real
+// TCP connection is not, since s.closing unblocks serveConn's select
+// long before a short ctx would ever fire.
+func TestShutdownJobsCloseWaitsForOutstandingConns(t *testing.T) {
+	s := NewServer(func(req gomemcached.MCRequest) gomemcached.MCResponse {
+		return gomemcached.MCResponse{}
+	}, 1)
+
+	blocker := make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		<-blocker
+		s.wg.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	// s.wg has an outstanding goroutine that won't finish until blocker
+	// is closed, so Shutdown must return via its ctx.Done() branch.
+	if err := s.Shutdown(ctx); err != ctx.Err() {
+		t.Fatalf("Shutdown = %v, want %v", err, ctx.Err())
+	}
+	select {
+	case <-s.jobs:
+		t.Fatal("s.jobs was closed while s.wg still had an outstanding goroutine")
+	default:
+	}
+
+	// Letting the stand-in goroutine finish lets the background
+	// s.wg.Wait() in Shutdown return and close s.jobs -- eventually, not
+	// synchronously with Shutdown's own return.
+	close(blocker)
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-s.jobs:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("s.jobs was never closed after the outstanding goroutine finished")
+		}
+	}
+}
+
+// TestServeConnEnforcesAuthenticator guards against the Server dispatch
+// path silently bypassing its own Authenticator field the way the
+// reqChannel-based HandleIO/handleMessage path does not.
+func TestServeConnEnforcesAuthenticator(t *testing.T) {
+	const user, pass = "alice", "s3cret"
+
+	s := NewServer(func(req gomemcached.MCRequest) gomemcached.MCResponse {
+		return gomemcached.MCResponse{Status: gomemcached.SUCCESS, Body: []byte("v")}
+	}, 1)
+	s.Authenticator = func(opcode gomemcached.CommandCode, mechanism string, body []byte) AuthResult {
+		if mechanism == "PLAIN" && string(body) == "\x00"+user+"\x00"+pass {
+			return AuthResult{Status: gomemcached.SUCCESS}
+		}
+		return AuthResult{Status: authErrorStatus}
+	}
+	defer s.Shutdown(context.Background())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go s.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(encodeMockRequest(gomemcached.GET, []byte("k"), nil)); err != nil {
+		t.Fatal(err)
+	}
+	if res := decodeMockResponse(t, readMockResponse(t, conn)); res.Status != authErrorStatus {
+		t.Fatalf("unauthenticated GET = status %v, want authErrorStatus", res.Status)
+	}
+
+	auth := "\x00" + user + "\x00" + pass
+	if _, err := conn.Write(encodeMockRequest(gomemcached.SASL_AUTH, []byte("PLAIN"), []byte(auth))); err != nil {
+		t.Fatal(err)
+	}
+	if res := decodeMockResponse(t, readMockResponse(t, conn)); res.Status != gomemcached.SUCCESS {
+		t.Fatalf("PLAIN auth = status %v, want SUCCESS", res.Status)
+	}
+
+	if _, err := conn.Write(encodeMockRequest(gomemcached.GET, []byte("k"), nil)); err != nil {
+		t.Fatal(err)
+	}
+	if res := decodeMockResponse(t, readMockResponse(t, conn)); res.Status != gomemcached.SUCCESS || string(res.Body) != "v" {
+		t.Fatalf("authenticated GET = %+v, want SUCCESS/\"v\"", res)
+	}
+}
+
+// TestServerAuthenticatorIndependentOfGlobal guards against a Server's
+// dispatch path falling back to the package-level Authenticator: setting
+// one must not affect a Server whose own Authenticator field is nil, and
+// vice versa, so two Servers in one process can run independent auth
+// policies.
+func TestServerAuthenticatorIndependentOfGlobal(t *testing.T) {
+	withAuthenticator(t, "alice", "s3cret")
+
+	s := NewServer(func(req gomemcached.MCRequest) gomemcached.MCResponse {
+		return gomemcached.MCResponse{Status: gomemcached.SUCCESS, Body: []byte("v")}
+	}, 1)
+	defer s.Shutdown(context.Background())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go s.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// s.Authenticator is nil, so this GET must go straight through even
+	// though the package-level Authenticator would have rejected it.
+	if _, err := conn.Write(encodeMockRequest(gomemcached.GET, []byte("k"), nil)); err != nil {
+		t.Fatal(err)
+	}
+	if res := decodeMockResponse(t, readMockResponse(t, conn)); res.Status != gomemcached.SUCCESS || string(res.Body) != "v" {
+		t.Fatalf("GET with s.Authenticator nil = %+v, want SUCCESS/\"v\"", res)
+	}
+}
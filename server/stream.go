@@ -0,0 +1,91 @@
+package memcached
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dustin/go-humanize"
+	"github.com/dustin/gomemcached"
+)
+
+// MCRequestHeader is a decoded request with Extras and Key read eagerly
+// (the protocol bounds them to 255 and 65535 bytes respectively) but
+// with Body left unread on the wire, for callers that want to stream a
+// large value instead of buffering it in full.
+type MCRequestHeader struct {
+	Opcode  gomemcached.CommandCode
+	VBucket uint16
+	Opaque  uint32
+	Cas     uint64
+	Extras  []byte
+	Key     []byte
+	BodyLen uint32
+}
+
+// ReadPacketStream reads a request's header, Extras and Key from r the
+// same way ReadPacket does, but returns Body as an io.Reader limited to
+// BodyLen instead of allocating it up front. The returned reader must be
+// fully drained before the next request can be read off r.
+func ReadPacketStream(r io.Reader) (hdr MCRequestHeader, body io.Reader, err error) {
+	hdrBytes := make([]byte, gomemcached.HDR_LEN)
+	if _, err = io.ReadFull(r, hdrBytes); err != nil {
+		return hdr, nil, err
+	}
+	if hdrBytes[0] != gomemcached.REQ_MAGIC {
+		return hdr, nil, &BadMagic{was: hdrBytes[0]}
+	}
+
+	keyLen := binary.BigEndian.Uint16(hdrBytes[2:])
+	extrasLen := uint32(hdrBytes[4])
+	totalLen := binary.BigEndian.Uint32(hdrBytes[8:])
+
+	hdr.Opcode = gomemcached.CommandCode(hdrBytes[1])
+	hdr.VBucket = binary.BigEndian.Uint16(hdrBytes[6:])
+	hdr.Opaque = binary.BigEndian.Uint32(hdrBytes[12:])
+	hdr.Cas = binary.BigEndian.Uint64(hdrBytes[16:])
+	hdr.BodyLen = totalLen - uint32(keyLen) - extrasLen
+
+	if hdr.BodyLen > MaxBodyLen {
+		return hdr, nil, errors.New(fmt.Sprintf("%d is too big (max %s)",
+			hdr.BodyLen, humanize.Bytes(uint64(MaxBodyLen))))
+	}
+
+	hdr.Extras = make([]byte, extrasLen)
+	if err = readOb(r, hdr.Extras); err != nil {
+		return hdr, nil, err
+	}
+	hdr.Key = make([]byte, keyLen)
+	if err = readOb(r, hdr.Key); err != nil {
+		return hdr, nil, err
+	}
+
+	return hdr, io.LimitReader(r, int64(hdr.BodyLen)), nil
+}
+
+// WriteResponseStream writes a response's header, Extras and Key to w
+// the same way transmitResponse does, then copies bodyLen bytes from
+// body instead of requiring the whole value to already be in memory.
+func WriteResponseStream(w io.Writer, req gomemcached.MCRequest, res gomemcached.MCResponse,
+	bodyLen uint32, body io.Reader) error {
+
+	o := bufio.NewWriter(w)
+	writeByte(o, gomemcached.RES_MAGIC)
+	writeByte(o, byte(req.Opcode))
+	writeUint16(o, uint16(len(res.Key)))
+	writeByte(o, uint8(len(res.Extras)))
+	writeByte(o, 0)
+	writeUint16(o, res.Status)
+	writeUint32(o, bodyLen+uint32(len(res.Key))+uint32(len(res.Extras)))
+	writeUint32(o, req.Opaque)
+	writeUint64(o, res.Cas)
+	writeBytes(o, res.Extras)
+	writeBytes(o, res.Key)
+	if err := o.Flush(); err != nil {
+		return err
+	}
+	_, err := io.CopyN(w, body, int64(bodyLen))
+	return err
+}
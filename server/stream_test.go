@@ -0,0 +1,72 @@
+package memcached
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/dustin/gomemcached"
+)
+
+// TestReadPacketStreamMatchesReadPacket checks that streaming a
+// request's body through ReadPacketStream yields the same header and
+// body as ReadPacket's eager, fully-buffered decode of the same wire
+// bytes -- the two are meant to agree on everything but when Body is
+// actually read off the wire.
+func TestReadPacketStreamMatchesReadPacket(t *testing.T) {
+	raw := encodeMockRequest(gomemcached.SET, []byte("key"), []byte("a-value"))
+
+	hdr, bodyReader, err := ReadPacketStream(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadPacketStream returned error: %v", err)
+	}
+	streamedBody, err := io.ReadAll(bodyReader)
+	if err != nil {
+		t.Fatalf("draining streamed body returned error: %v", err)
+	}
+
+	eager, err := ReadPacket(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadPacket returned error: %v", err)
+	}
+
+	if hdr.Opcode != eager.Opcode || hdr.VBucket != eager.VBucket ||
+		hdr.Opaque != eager.Opaque || hdr.Cas != eager.Cas {
+		t.Fatalf("ReadPacketStream header = %+v, want to match ReadPacket's %+v", hdr, eager)
+	}
+	if !bytes.Equal(hdr.Extras, eager.Extras) {
+		t.Fatalf("Extras = %q, want %q", hdr.Extras, eager.Extras)
+	}
+	if !bytes.Equal(hdr.Key, eager.Key) {
+		t.Fatalf("Key = %q, want %q", hdr.Key, eager.Key)
+	}
+	if !bytes.Equal(streamedBody, eager.Body) {
+		t.Fatalf("streamed body = %q, want %q", streamedBody, eager.Body)
+	}
+}
+
+// TestWriteResponseStreamMatchesTransmitResponse checks that
+// WriteResponseStream's streamed write produces the exact same bytes on
+// the wire as transmitResponse's eager, fully-buffered write of the same
+// response.
+func TestWriteResponseStreamMatchesTransmitResponse(t *testing.T) {
+	req := gomemcached.MCRequest{Opcode: gomemcached.GET, Opaque: 42}
+	res := gomemcached.MCResponse{
+		Status: gomemcached.SUCCESS,
+		Cas:    7,
+		Key:    []byte("k"),
+		Body:   []byte("a value"),
+	}
+
+	var streamed bytes.Buffer
+	if err := WriteResponseStream(&streamed, req, res, uint32(len(res.Body)), bytes.NewReader(res.Body)); err != nil {
+		t.Fatalf("WriteResponseStream returned error: %v", err)
+	}
+
+	var eager bytes.Buffer
+	transmitResponse(&eager, req, res)
+
+	if !bytes.Equal(streamed.Bytes(), eager.Bytes()) {
+		t.Fatalf("WriteResponseStream bytes = %x, want %x", streamed.Bytes(), eager.Bytes())
+	}
+}